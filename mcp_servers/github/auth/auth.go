@@ -0,0 +1,77 @@
+// Package auth provides pluggable GitHub authentication for the MCP server.
+// The mode is selected at startup via the AUTH_MODE environment variable so
+// the same binary can serve personal-access-token clients, browser-based
+// OAuth2 logins, and GitHub App installations without code changes.
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	gogithub "github.com/google/go-github/v69/github"
+)
+
+// Mode selects which Authenticator implementation New constructs.
+type Mode string
+
+const (
+	ModePAT       Mode = "pat"
+	ModeOAuth2    Mode = "oauth2"
+	ModeGitHubApp Mode = "github_app"
+)
+
+// contextKey namespaces values this package stashes on the request context
+// so they can't collide with keys set by other packages.
+type contextKey string
+
+const tokenContextKey contextKey = "auth_token"
+
+// Authenticator extracts a credential from an inbound HTTP request and
+// produces a GitHub client authenticated with whatever credential the
+// context returned by Authenticate carries. Implementations must be safe
+// for concurrent use, since a single Authenticator is shared across all
+// requests.
+type Authenticator interface {
+	// Authenticate inspects r and returns a context carrying the
+	// credential it found. A missing credential is not an error -- Client
+	// falls back to an unauthenticated client. The implementations in this
+	// package never return an error: undecodable auth data is logged and
+	// treated as no credential.
+	Authenticate(ctx context.Context, r *http.Request) (context.Context, error)
+	// Client returns a GitHub client authenticated using the credential
+	// Authenticate stashed in ctx, or an unauthenticated client if none
+	// is present.
+	Client(ctx context.Context) (*gogithub.Client, error)
+}
+
+// New constructs the Authenticator selected by the AUTH_MODE environment
+// variable, defaulting to ModePAT when it is unset. All modes share the
+// same GitHub Enterprise Server configuration (GITHUB_API_URL,
+// GITHUB_UPLOAD_URL, GITHUB_ROOT_CA) and the same rate-limit-aware,
+// cached, metrics-instrumented transport (GITHUB_RATE_LIMIT_THRESHOLD,
+// REDIS_URL). The github_api_* metrics are labeled by installationLabel
+// (installation ID, or "oauth2"/"pat"), not by MCP tool -- see the comment on metrics.go's vars for why.
+func New() (Authenticator, error) {
+	mode := Mode(os.Getenv("AUTH_MODE"))
+	if mode == "" {
+		mode = ModePAT
+	}
+
+	ent, err := loadEnterpriseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch mode {
+	case ModePAT:
+		return NewPATAuthenticator(ent), nil
+	case ModeOAuth2:
+		return NewOAuth2Authenticator(ent)
+	case ModeGitHubApp:
+		return NewGitHubAppAuthenticator(ent)
+	default:
+		return nil, fmt.Errorf("auth: unknown AUTH_MODE %q", mode)
+	}
+}
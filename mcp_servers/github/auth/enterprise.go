@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	gogithub "github.com/google/go-github/v69/github"
+)
+
+// baseURLContextKey carries a per-request Enterprise Server host, letting a
+// single MCP server multiplex between github.com and multiple enterprise
+// hosts instead of being pinned to whatever GITHUB_API_URL was at startup.
+const baseURLContextKey contextKey = "github_base_url"
+
+// enterpriseConfig holds the default GitHub Enterprise Server endpoints for
+// this process (GITHUB_API_URL/GITHUB_UPLOAD_URL) plus the shared transport,
+// which trusts an optional custom CA bundle for on-prem installs with
+// private certificates. Built by loadEnterpriseConfig with none of those
+// variables set, it targets github.com with the standard TLS trust store,
+// same as before Enterprise support existed.
+type enterpriseConfig struct {
+	apiURL    string
+	uploadURL string
+	rt        *rateLimitedTransport
+}
+
+// loadEnterpriseConfig reads GITHUB_API_URL, GITHUB_UPLOAD_URL and the
+// optional GITHUB_ROOT_CA PEM path, mirroring the approach dex's GitHub
+// connector uses for on-prem installs.
+func loadEnterpriseConfig() (*enterpriseConfig, error) {
+	cfg := &enterpriseConfig{
+		apiURL:    os.Getenv("GITHUB_API_URL"),
+		uploadURL: os.Getenv("GITHUB_UPLOAD_URL"),
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if caPath := os.Getenv("GITHUB_ROOT_CA"); caPath != "" {
+		pemBytes, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("auth: reading GITHUB_ROOT_CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("auth: GITHUB_ROOT_CA %q contains no valid certificates", caPath)
+		}
+		transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	cfg.rt = newRateLimitedTransport(transport)
+
+	return cfg, nil
+}
+
+// newGitHubClient builds a GitHub client authenticated with token. baseURL,
+// when non-empty, overrides the process-wide GITHUB_API_URL/GITHUB_UPLOAD_URL
+// for this one client (used for the per-request base_url override); with
+// GITHUB_API_URL unset, an empty token and baseURL together produce an
+// unauthenticated github.com client, as callers got before Enterprise
+// support existed. Every client shares
+// this config's rateLimitedTransport, so HTTP caching and rate-limit
+// backoff apply no matter which auth mode constructed the client.
+func (c *enterpriseConfig) newGitHubClient(token, baseURL string) (*gogithub.Client, error) {
+	client := gogithub.NewClient(&http.Client{Transport: c.rt})
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+
+	apiURL, uploadURL := c.apiURL, c.uploadURL
+	if baseURL != "" {
+		apiURL, uploadURL = baseURL, baseURL
+	}
+	if apiURL == "" {
+		return client, nil
+	}
+	if uploadURL == "" {
+		uploadURL = apiURL
+	}
+
+	return client.WithEnterpriseURLs(apiURL, uploadURL)
+}
+
+// baseURLFromAuthData extracts an optional per-request "base_url" field from
+// the x-auth-data JSON payload.
+func baseURLFromAuthData(r *http.Request) string {
+	headerData := r.Header.Get("x-auth-data")
+	if headerData == "" {
+		return ""
+	}
+	payload, err := decodeAuthData(headerData)
+	if err != nil {
+		return ""
+	}
+	baseURL, _ := payload["base_url"].(string)
+	return baseURL
+}
+
+func withBaseURL(ctx context.Context, baseURL string) context.Context {
+	if baseURL == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, baseURLContextKey, baseURL)
+}
+
+func baseURLFromContext(ctx context.Context) string {
+	baseURL, _ := ctx.Value(baseURLContextKey).(string)
+	return baseURL
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBaseURLFromAuthData(t *testing.T) {
+	raw := `{"base_url":"https://ghe.example.com/api/v3"}`
+	encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+
+	r := httptest.NewRequest(http.MethodPost, "/mcp/", nil)
+	r.Header.Set("x-auth-data", encoded)
+
+	if got, want := baseURLFromAuthData(r), "https://ghe.example.com/api/v3"; got != want {
+		t.Errorf("baseURLFromAuthData() = %q, want %q", got, want)
+	}
+}
+
+func TestBaseURLFromAuthDataMissingHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/mcp/", nil)
+	if got := baseURLFromAuthData(r); got != "" {
+		t.Errorf("baseURLFromAuthData() = %q, want empty string", got)
+	}
+}
+
+func TestBaseURLFromAuthDataMalformedHeader(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/mcp/", nil)
+	r.Header.Set("x-auth-data", "not valid base64!!")
+
+	if got := baseURLFromAuthData(r); got != "" {
+		t.Errorf("baseURLFromAuthData() = %q, want empty string for malformed header", got)
+	}
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	gogithub "github.com/google/go-github/v69/github"
+	"github.com/golang-jwt/jwt/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const installationContextKey contextKey = "installation_id"
+
+// cachedInstallationToken pairs a minted GitHub App installation access
+// token with its expiry so GitHubAppAuthenticator can reuse it across
+// requests until it is close to expiring.
+type cachedInstallationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// GitHubAppAuthenticator authenticates as a GitHub App installation. It
+// signs a short-lived JWT with the app's private key to mint installation
+// access tokens on demand, caching each installation's token until 60
+// seconds before it expires.
+type GitHubAppAuthenticator struct {
+	appID            string
+	privateKey       *rsa.PrivateKey
+	defaultInstallID int64
+	ent              *enterpriseConfig
+
+	mu     sync.Mutex
+	tokens map[int64]cachedInstallationToken
+}
+
+// NewGitHubAppAuthenticator builds a GitHubAppAuthenticator from the
+// GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_PATH environment variables.
+// GITHUB_APP_INSTALLATION_ID is optional and used when a request doesn't
+// carry an installation_id of its own.
+func NewGitHubAppAuthenticator(ent *enterpriseConfig) (*GitHubAppAuthenticator, error) {
+	appID := os.Getenv("GITHUB_APP_ID")
+	keyPath := os.Getenv("GITHUB_APP_PRIVATE_KEY_PATH")
+	if appID == "" || keyPath == "" {
+		return nil, fmt.Errorf("auth: GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_PATH are required for github_app mode")
+	}
+
+	keyBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading GitHub App private key: %w", err)
+	}
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("auth: parsing GitHub App private key: %w", err)
+	}
+
+	var defaultInstallID int64
+	if raw := os.Getenv("GITHUB_APP_INSTALLATION_ID"); raw != "" {
+		defaultInstallID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("auth: invalid GITHUB_APP_INSTALLATION_ID: %w", err)
+		}
+	}
+
+	return &GitHubAppAuthenticator{
+		appID:            appID,
+		privateKey:       privateKey,
+		defaultInstallID: defaultInstallID,
+		ent:              ent,
+		tokens:           make(map[int64]cachedInstallationToken),
+	}, nil
+}
+
+func (a *GitHubAppAuthenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	ctx = withBaseURL(ctx, baseURLFromAuthData(r))
+
+	// Headless clients may still authenticate with a plain PAT even when
+	// the server is running in github_app mode.
+	if token := extractAccessToken(r); token != "" {
+		return context.WithValue(ctx, tokenContextKey, token), nil
+	}
+
+	installID := a.defaultInstallID
+	if headerData := r.Header.Get("x-auth-data"); headerData != "" {
+		payload, err := decodeAuthData(headerData)
+		if err != nil {
+			log.WithError(err).Warn("Failed to decode base64 auth data")
+			return ctx, nil
+		}
+		switch v := payload["installation_id"].(type) {
+		case float64:
+			installID = int64(v)
+		case string:
+			if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+				installID = parsed
+			}
+		}
+	}
+
+	if installID == 0 {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, installationContextKey, installID), nil
+}
+
+func (a *GitHubAppAuthenticator) Client(ctx context.Context) (*gogithub.Client, error) {
+	baseURL := baseURLFromContext(ctx)
+
+	if token, ok := ctx.Value(tokenContextKey).(string); ok && token != "" {
+		return a.ent.newGitHubClient(token, baseURL)
+	}
+
+	installID, ok := ctx.Value(installationContextKey).(int64)
+	if !ok || installID == 0 {
+		log.Warn("No GitHub App installation ID found in context")
+		return a.ent.newGitHubClient("", baseURL)
+	}
+
+	token, err := a.installationAccessToken(ctx, installID, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return a.ent.newGitHubClient(token, baseURL)
+}
+
+func (a *GitHubAppAuthenticator) installationAccessToken(ctx context.Context, installID int64, baseURL string) (string, error) {
+	a.mu.Lock()
+	if cached, ok := a.tokens[installID]; ok && time.Until(cached.expiresAt) > 60*time.Second {
+		a.mu.Unlock()
+		return cached.token, nil
+	}
+	a.mu.Unlock()
+
+	appJWT, err := a.signAppJWT()
+	if err != nil {
+		return "", fmt.Errorf("auth: signing app JWT: %w", err)
+	}
+
+	appClient, err := a.ent.newGitHubClient(appJWT, baseURL)
+	if err != nil {
+		return "", fmt.Errorf("auth: building app client: %w", err)
+	}
+	installToken, _, err := appClient.Apps.CreateInstallationToken(ctx, installID, nil)
+	if err != nil {
+		return "", fmt.Errorf("auth: minting installation token: %w", err)
+	}
+
+	a.mu.Lock()
+	a.tokens[installID] = cachedInstallationToken{
+		token:     installToken.GetToken(),
+		expiresAt: installToken.GetExpiresAt().Time,
+	}
+	a.mu.Unlock()
+
+	return installToken.GetToken(), nil
+}
+
+// signAppJWT produces the short-lived JWT GitHub requires to authenticate
+// as the app itself (as opposed to one of its installations). iat is
+// backdated by 30s to tolerate clock drift; exp is capped well under
+// GitHub's 10 minute limit.
+func (a *GitHubAppAuthenticator) signAppJWT() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    a.appID,
+		IssuedAt:  jwt.NewNumericDate(now.Add(-30 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	return token.SignedString(a.privateKey)
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// These are registered against the default Prometheus registry so
+// runServer can serve them by mounting promhttp.Handler() on /metrics
+// without threading a registry through the auth package.
+//
+// These are intentionally labeled by installation only, not by MCP tool.
+// The transport sees only the outgoing *http.Request; the tool name lives
+// one layer up, in github.NewServer's per-tool dispatch (imported from
+// github.com/github/github-mcp-server), which calls our GetClientFn with a
+// context that carries no tool identifier today. Labeling by tool would
+// need that dispatch to stash the tool name on the context before
+// invoking GetClientFn -- out of scope here since we don't own that
+// package. If/when that hook exists, thread it through the same
+// installationLabel-style helper rather than adding it back silently.
+var (
+	// apiRequestsTotal counts every GitHub API call the rate-limited
+	// transport issues, labeled by installationLabel of the credential it
+	// ran as and the response status code.
+	apiRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_requests_total",
+		Help: "Total number of requests made to the GitHub API.",
+	}, []string{"installation", "status"})
+
+	// apiRateLimitRemaining tracks the X-RateLimit-Remaining value GitHub
+	// last reported under each installationLabel, so dashboards can alert
+	// before a client exhausts its quota. All PAT-authenticated requests
+	// share the "pat" series (and all OAuth2 sessions the "oauth2" one),
+	// so for those it holds whichever credential reported last.
+	apiRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_api_rate_limit_remaining",
+		Help: "Remaining GitHub API rate limit, as last reported by X-RateLimit-Remaining.",
+	}, []string{"installation"})
+
+	// apiRequestDuration measures end-to-end latency of GitHub API calls,
+	// including any time spent blocked waiting out a rate limit.
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_api_request_duration_seconds",
+		Help:    "Latency of GitHub API requests, including rate-limit wait time.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"installation"})
+)
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gogithub "github.com/google/go-github/v69/github"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	ghoauth "golang.org/x/oauth2/github"
+)
+
+const (
+	oauthStateCookie   = "gh_oauth_state"
+	oauthSessionCookie = "gh_session_id"
+
+	sessionContextKey contextKey = "session_id"
+
+	// sessionTTL bounds how long a stored session (and the refresh token it
+	// carries) is kept once its cookie is minted, matching the cookie's own
+	// MaxAge so the two expire together.
+	sessionTTL = 30 * 24 * time.Hour
+)
+
+// OAuth2Authenticator authenticates browser sessions established through
+// the GitHub OAuth2 authorization-code flow registered by RegisterRoutes,
+// and falls back to a plain PAT in the x-auth-data header for headless MCP
+// clients that never see a browser.
+type OAuth2Authenticator struct {
+	config   *oauth2.Config
+	signer   *cookieSigner
+	sessions *sessionStore
+	ent      *enterpriseConfig
+}
+
+// NewOAuth2Authenticator builds an OAuth2Authenticator from the
+// GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET, GITHUB_REDIRECT_URL and
+// GITHUB_SCOPES environment variables.
+func NewOAuth2Authenticator(ent *enterpriseConfig) (*OAuth2Authenticator, error) {
+	clientID := os.Getenv("GITHUB_CLIENT_ID")
+	clientSecret := os.Getenv("GITHUB_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("auth: GITHUB_CLIENT_ID and GITHUB_CLIENT_SECRET are required for oauth2 mode")
+	}
+
+	scopes := []string{"repo", "read:user"}
+	if raw := os.Getenv("GITHUB_SCOPES"); raw != "" {
+		scopes = nil
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+
+	return &OAuth2Authenticator{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  os.Getenv("GITHUB_REDIRECT_URL"),
+			Scopes:       scopes,
+			Endpoint:     ghoauth.Endpoint,
+		},
+		signer:   newCookieSigner(),
+		sessions: newSessionStore(),
+		ent:      ent,
+	}, nil
+}
+
+func (a *OAuth2Authenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	ctx = withBaseURL(ctx, baseURLFromAuthData(r))
+
+	if token := extractAccessToken(r); token != "" {
+		return context.WithValue(ctx, tokenContextKey, token), nil
+	}
+
+	if cookie, err := r.Cookie(oauthSessionCookie); err == nil {
+		if sessionID, ok := a.signer.verify(cookie.Value); ok {
+			return context.WithValue(ctx, sessionContextKey, sessionID), nil
+		}
+	}
+
+	return ctx, nil
+}
+
+func (a *OAuth2Authenticator) Client(ctx context.Context) (*gogithub.Client, error) {
+	baseURL := baseURLFromContext(ctx)
+
+	if sessionID, ok := ctx.Value(sessionContextKey).(string); ok {
+		if tok, found := a.sessions.get(sessionID); found {
+			// Route the token refresh (and every subsequent API call) through
+			// the shared enterprise transport, so an OAuth2 session gets the
+			// same CA trust, HTTP caching, rate-limit backoff, and metrics as
+			// every other auth mode instead of oauth2's bare default client.
+			baseCtx := context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: a.ent.rt})
+			httpClient := oauth2.NewClient(baseCtx, a.config.TokenSource(baseCtx, tok))
+			client := gogithub.NewClient(httpClient)
+			apiURL, uploadURL := a.ent.apiURL, a.ent.uploadURL
+			if baseURL != "" {
+				apiURL, uploadURL = baseURL, baseURL
+			}
+			if apiURL == "" {
+				return client, nil
+			}
+			if uploadURL == "" {
+				uploadURL = apiURL
+			}
+			return client.WithEnterpriseURLs(apiURL, uploadURL)
+		}
+		log.Warn("Session cookie present but no matching OAuth2 token found")
+	}
+
+	token, _ := ctx.Value(tokenContextKey).(string)
+	if token == "" {
+		log.Warn("No auth token found in context")
+	}
+	return a.ent.newGitHubClient(token, baseURL)
+}
+
+// RegisterRoutes wires the /oauth/github/login and /oauth/github/callback
+// handlers onto mux. runServer type-asserts for this optional method, so
+// only auth modes that need browser routes register any.
+func (a *OAuth2Authenticator) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/oauth/github/login", a.handleLogin)
+	mux.HandleFunc("/oauth/github/callback", a.handleCallback)
+}
+
+// handleLogin starts the OAuth2 authorization-code flow by redirecting the
+// browser to GitHub with a signed, random state value stashed in a cookie
+// so the callback can detect CSRF.
+func (a *OAuth2Authenticator) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state := randomID()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    a.signer.sign(state),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(10 * time.Minute / time.Second),
+	})
+
+	http.Redirect(w, r, a.config.AuthCodeURL(state, oauth2.AccessTypeOffline), http.StatusFound)
+}
+
+// handleCallback validates the CSRF state, exchanges the authorization code
+// for a token, and stores the token in the session store keyed by a signed
+// session cookie handed back to the browser.
+func (a *OAuth2Authenticator) handleCallback(w http.ResponseWriter, r *http.Request) {
+	stateCookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		http.Error(w, "missing oauth state cookie", http.StatusBadRequest)
+		return
+	}
+	wantState, ok := a.signer.verify(stateCookie.Value)
+	if !ok || r.URL.Query().Get("state") != wantState {
+		http.Error(w, "invalid oauth state", http.StatusBadRequest)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "missing authorization code", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := a.config.Exchange(r.Context(), code)
+	if err != nil {
+		log.WithError(err).Error("Failed to exchange OAuth2 code for token")
+		http.Error(w, "failed to exchange authorization code", http.StatusBadGateway)
+		return
+	}
+
+	sessionID := randomID()
+	a.sessions.put(sessionID, tok)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthSessionCookie,
+		Value:    a.signer.sign(sessionID),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(sessionTTL / time.Second),
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:   oauthStateCookie,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	fmt.Fprint(w, "GitHub authentication successful, you can close this window.")
+}
+
+// sessionEntry pairs a stored OAuth2 token with when it should be evicted.
+type sessionEntry struct {
+	token     *oauth2.Token
+	expiresAt time.Time
+}
+
+// sessionStore holds OAuth2 tokens for browser-based logins, keyed by an
+// opaque session ID handed to the client via a signed cookie. Entries are
+// evicted lazily on get and swept periodically once their sessionTTL
+// passes, so a long-running server doesn't grow this map forever or hold
+// onto a dead refresh token past its cookie's lifetime.
+type sessionStore struct {
+	mu     sync.RWMutex
+	tokens map[string]sessionEntry
+}
+
+func newSessionStore() *sessionStore {
+	s := &sessionStore{tokens: make(map[string]sessionEntry)}
+	go s.sweepLoop()
+	return s
+}
+
+func (s *sessionStore) get(id string) (*oauth2.Token, bool) {
+	s.mu.RLock()
+	entry, ok := s.tokens[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		s.mu.Lock()
+		delete(s.tokens, id)
+		s.mu.Unlock()
+		return nil, false
+	}
+	return entry.token, true
+}
+
+func (s *sessionStore) put(id string, tok *oauth2.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[id] = sessionEntry{token: tok, expiresAt: time.Now().Add(sessionTTL)}
+}
+
+// sweepLoop periodically evicts expired sessions, so entries whose cookie
+// the client never brings back again don't just sit in memory until the
+// process restarts.
+func (s *sessionStore) sweepLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.sweep()
+	}
+}
+
+func (s *sessionStore) sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, entry := range s.tokens {
+		if now.After(entry.expiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+}
+
+// cookieSigner signs and verifies short opaque values (OAuth state, session
+// IDs) using HMAC-SHA256 so a client can hold the value in a cookie without
+// being able to forge or tamper with it.
+type cookieSigner struct {
+	secret []byte
+}
+
+func newCookieSigner() *cookieSigner {
+	secret := os.Getenv("GITHUB_OAUTH_COOKIE_SECRET")
+	if secret == "" {
+		// Fall back to a random per-process secret so the server still
+		// works for local development; this invalidates sessions on
+		// restart, which is acceptable since it's a fallback.
+		buf := make([]byte, 32)
+		_, _ = rand.Read(buf)
+		secret = hex.EncodeToString(buf)
+	}
+	return &cookieSigner{secret: []byte(secret)}
+}
+
+func (c *cookieSigner) sign(value string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(value))
+	sig := hex.EncodeToString(mac.Sum(nil))
+	return value + "." + sig
+}
+
+func (c *cookieSigner) verify(signed string) (string, bool) {
+	idx := len(signed) - 65 // 64 hex chars + separator
+	if idx <= 0 || signed[idx] != '.' {
+		return "", false
+	}
+	value, sig := signed[:idx], signed[idx+1:]
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(value))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", false
+	}
+	return value, true
+}
+
+func randomID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
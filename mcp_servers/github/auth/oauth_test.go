@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCookieSignerSignVerify(t *testing.T) {
+	signer := newCookieSigner()
+
+	signed := signer.sign("session-123")
+	value, ok := signer.verify(signed)
+	if !ok || value != "session-123" {
+		t.Fatalf("verify(%q) = (%q, %v), want (\"session-123\", true)", signed, value, ok)
+	}
+}
+
+func TestCookieSignerVerifyRejectsTampering(t *testing.T) {
+	signer := newCookieSigner()
+	signed := signer.sign("session-123")
+
+	replacement := "0"
+	if signed[len(signed)-1] == '0' {
+		replacement = "1"
+	}
+	tampered := signed[:len(signed)-1] + replacement
+	if _, ok := signer.verify(tampered); ok {
+		t.Fatal("verify accepted a tampered signature")
+	}
+}
+
+func TestCookieSignerVerifyRejectsForeignSecret(t *testing.T) {
+	a := newCookieSigner()
+	b := newCookieSigner()
+
+	if _, ok := b.verify(a.sign("session-123")); ok {
+		t.Fatal("verify accepted a signature produced with a different secret")
+	}
+}
+
+func TestCookieSignerVerifyRejectsMalformedInput(t *testing.T) {
+	signer := newCookieSigner()
+
+	for _, in := range []string{"", "short", strings.Repeat("a", 70)} {
+		if _, ok := signer.verify(in); ok {
+			t.Errorf("verify(%q) unexpectedly succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	gogithub "github.com/google/go-github/v69/github"
+	log "github.com/sirupsen/logrus"
+)
+
+// PATAuthenticator authenticates requests using a personal access token
+// supplied either via the AUTH_DATA environment variable or a base64-encoded
+// JSON payload in the x-auth-data header. It is the original auth mode and
+// remains the default, and the fallback headless MCP clients use under the
+// other modes.
+type PATAuthenticator struct {
+	ent *enterpriseConfig
+}
+
+// NewPATAuthenticator returns an Authenticator backed by a bearer token,
+// targeting ent's GitHub Enterprise Server host unless a request overrides
+// it with its own base_url.
+func NewPATAuthenticator(ent *enterpriseConfig) *PATAuthenticator {
+	return &PATAuthenticator{ent: ent}
+}
+
+func (a *PATAuthenticator) Authenticate(ctx context.Context, r *http.Request) (context.Context, error) {
+	ctx = withBaseURL(ctx, baseURLFromAuthData(r))
+	if token := extractAccessToken(r); token != "" {
+		return context.WithValue(ctx, tokenContextKey, token), nil
+	}
+	return ctx, nil
+}
+
+func (a *PATAuthenticator) Client(ctx context.Context) (*gogithub.Client, error) {
+	token, ok := ctx.Value(tokenContextKey).(string)
+	if !ok || token == "" {
+		log.Warn("No auth token found in context")
+	}
+	return a.ent.newGitHubClient(token, baseURLFromContext(ctx))
+}
+
+// decodeAuthData base64-decodes and JSON-unmarshals the x-auth-data header
+// payload shared by all auth modes.
+func decodeAuthData(headerData string) (map[string]interface{}, error) {
+	decoded, err := base64.StdEncoding.DecodeString(headerData)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(decoded, &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func extractAccessToken(r *http.Request) string {
+	// First try AUTH_DATA environment variable
+	authData := os.Getenv("AUTH_DATA")
+
+	var payload map[string]interface{}
+	if authData != "" {
+		if err := json.Unmarshal([]byte(authData), &payload); err != nil {
+			log.WithError(err).Warn("Failed to parse auth data JSON")
+			return ""
+		}
+	} else if headerData := r.Header.Get("x-auth-data"); headerData != "" {
+		decoded, err := decodeAuthData(headerData)
+		if err != nil {
+			log.WithError(err).Warn("Failed to decode base64 auth data")
+			return ""
+		}
+		payload = decoded
+	}
+
+	if payload == nil {
+		return ""
+	}
+
+	// Extract access_token field
+	if accessToken, ok := payload["access_token"].(string); ok {
+		return accessToken
+	}
+
+	return ""
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeAuthData(t *testing.T) {
+	raw := `{"access_token":"tok","base_url":"https://ghe.example.com/api/v3"}`
+	encoded := base64.StdEncoding.EncodeToString([]byte(raw))
+
+	payload, err := decodeAuthData(encoded)
+	if err != nil {
+		t.Fatalf("decodeAuthData returned error: %v", err)
+	}
+	if payload["access_token"] != "tok" {
+		t.Errorf("access_token = %v, want %q", payload["access_token"], "tok")
+	}
+}
+
+func TestDecodeAuthDataInvalidBase64(t *testing.T) {
+	if _, err := decodeAuthData("not valid base64!!"); err == nil {
+		t.Fatal("expected an error for invalid base64 input")
+	}
+}
+
+func TestDecodeAuthDataInvalidJSON(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("not json"))
+	if _, err := decodeAuthData(encoded); err == nil {
+		t.Fatal("expected an error for non-JSON payload")
+	}
+}
@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// installationLabel identifies, for metrics purposes, which credential a
+// request ran as: a GitHub App installation ID, or, for credentials that
+// aren't installation-scoped, "oauth2" for an OAuth2 session and "pat" for
+// everything else (including unauthenticated requests).
+func installationLabel(ctx context.Context) string {
+	if installID, ok := ctx.Value(installationContextKey).(int64); ok && installID != 0 {
+		return strconv.FormatInt(installID, 10)
+	}
+	if _, ok := ctx.Value(sessionContextKey).(string); ok {
+		return "oauth2"
+	}
+	return "pat"
+}
+
+// credentialKey identifies, for rate-limit tracking and cache partitioning,
+// which credential a request authenticated with. It prefers the bearer
+// token when one is present (pat mode, and github_app's headless PAT
+// fallback), falls back to the installation ID for github_app mode proper,
+// which looks up (or mints and caches) its installation token in Client()
+// rather than ever stashing one on the context, and falls back to the OAuth2 session
+// ID for oauth2 mode, which likewise never puts the underlying token on
+// the context. Any of these falling through to one shared key would put
+// every credential behind one limiter and cache partition, defeating the
+// point of keying by credential at all.
+func credentialKey(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenContextKey).(string); ok && token != "" {
+		return "token:" + token
+	}
+	if installID, ok := ctx.Value(installationContextKey).(int64); ok && installID != 0 {
+		return "installation:" + strconv.FormatInt(installID, 10)
+	}
+	if sessionID, ok := ctx.Value(sessionContextKey).(string); ok && sessionID != "" {
+		return "session:" + sessionID
+	}
+	return "anonymous"
+}
+
+// rateLimitThreshold is the X-RateLimit-Remaining value under which
+// tokenLimiter starts delaying requests for that token until the primary
+// window resets, instead of letting the caller run the quota to zero.
+// Override with GITHUB_RATE_LIMIT_THRESHOLD.
+var rateLimitThreshold = func() int {
+	if raw := os.Getenv("GITHUB_RATE_LIMIT_THRESHOLD"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			return n
+		}
+	}
+	return 50
+}()
+
+// tokenLimiter tracks the most recent rate-limit state GitHub reported for
+// a single token, so every request sharing that token backs off together
+// rather than each discovering the limit independently.
+type tokenLimiter struct {
+	mu           sync.Mutex
+	remaining    int
+	resetAt      time.Time
+	backoff      time.Duration
+	blockedUntil time.Time
+}
+
+// wait blocks until it's safe to issue another request for this token, or
+// returns early if ctx is canceled first.
+func (l *tokenLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	until := l.blockedUntil
+	l.mu.Unlock()
+
+	if until.IsZero() {
+		return nil
+	}
+	delay := time.Until(until)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update folds a response's rate-limit headers into the limiter's state:
+// it records the primary window's remaining/reset, and on a secondary
+// rate-limit response (403 with Retry-After) grows an exponential backoff.
+func (l *tokenLimiter) update(resp *http.Response, installation string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		l.remaining = remaining
+		apiRateLimitRemaining.WithLabelValues(installation).Set(float64(remaining))
+		if resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			l.resetAt = time.Unix(resetUnix, 0)
+		}
+	}
+
+	if retryAfter := retryAfterDuration(resp); resp.StatusCode == http.StatusForbidden && retryAfter > 0 {
+		if l.backoff == 0 {
+			l.backoff = time.Second
+		} else {
+			l.backoff *= 2
+		}
+		if retryAfter > l.backoff {
+			l.backoff = retryAfter
+		}
+		l.blockedUntil = time.Now().Add(l.backoff)
+		log.WithField("installation", installation).Warnf("GitHub secondary rate limit hit, backing off %s", l.backoff)
+		return
+	}
+
+	// A clean response clears the secondary-limit backoff; only the
+	// primary window's threshold governs any further waiting.
+	l.backoff = 0
+	if l.remaining < rateLimitThreshold && !l.resetAt.IsZero() {
+		l.blockedUntil = l.resetAt
+	} else {
+		l.blockedUntil = time.Time{}
+	}
+}
+
+func retryAfterDuration(resp *http.Response) time.Duration {
+	raw := resp.Header.Get("Retry-After")
+	if raw == "" {
+		return 0
+	}
+	secs, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// tokenState bundles the per-credential state rateLimitedTransport keeps
+// isolated between tokens: the rate-limit tracker and a cache transport
+// whose keys are namespaced to this credential, so one token's cached
+// (possibly authenticated) response is never served back to another.
+type tokenState struct {
+	limiter *tokenLimiter
+	cache   http.RoundTripper // httpcache.Transport scoped to this credential
+}
+
+// rateLimitedTransport wraps an HTTP cache transport with per-token
+// rate-limit tracking and Prometheus metrics. One is built per
+// enterpriseConfig and shared across every token that Authenticator hands
+// it, since the limiter state is only useful when shared across requests
+// for the same credential; the underlying cache store is likewise shared
+// for efficiency, but every credential gets its own key namespace within
+// it via tokenState.
+type rateLimitedTransport struct {
+	next  http.RoundTripper
+	store httpcache.Cache
+
+	mu     sync.Mutex
+	tokens map[string]*tokenState
+}
+
+func newRateLimitedTransport(next http.RoundTripper) *rateLimitedTransport {
+	return &rateLimitedTransport{
+		next:   next,
+		store:  newHTTPCache(),
+		tokens: make(map[string]*tokenState),
+	}
+}
+
+func (t *rateLimitedTransport) stateFor(key string) *tokenState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.tokens[key]
+	if !ok {
+		s = &tokenState{
+			limiter: &tokenLimiter{},
+			cache: &httpcache.Transport{
+				Transport:           t.next,
+				Cache:               &partitionedCache{partition: partitionKey(key), store: t.store},
+				MarkCachedResponses: true,
+			},
+		}
+		t.tokens[key] = s
+	}
+	return s
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	installation := installationLabel(ctx)
+
+	state := t.stateFor(credentialKey(ctx))
+	start := time.Now()
+	if err := state.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := state.cache.RoundTrip(req)
+	apiRequestDuration.WithLabelValues(installation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiRequestsTotal.WithLabelValues(installation, "error").Inc()
+		return resp, err
+	}
+
+	state.limiter.update(resp, installation)
+	apiRequestsTotal.WithLabelValues(installation, strconv.Itoa(resp.StatusCode)).Inc()
+	return resp, nil
+}
+
+// partitionKey derives the cache-key namespace for a credential. It hashes
+// the credential rather than using it verbatim so a bearer token never ends
+// up readable inside an httpcache key, e.g. as a Redis key when REDIS_URL
+// is set.
+func partitionKey(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// partitionedCache namespaces a shared httpcache.Cache by credential, so
+// two tokens requesting the same URL never share a cached response -- this
+// matters because httpcache's default cache key is just the request URL,
+// which says nothing about which credential authenticated the response
+// stored under it.
+type partitionedCache struct {
+	partition string
+	store     httpcache.Cache
+}
+
+func (c *partitionedCache) key(key string) string {
+	return c.partition + ":" + key
+}
+
+func (c *partitionedCache) Get(key string) ([]byte, bool) {
+	return c.store.Get(c.key(key))
+}
+
+func (c *partitionedCache) Set(key string, responseBytes []byte) {
+	c.store.Set(c.key(key), responseBytes)
+}
+
+func (c *partitionedCache) Delete(key string) {
+	c.store.Delete(c.key(key))
+}
+
+// newHTTPCache returns the httpcache.Cache backing the shared transport:
+// Redis when REDIS_URL is set, so cached responses survive restarts and
+// are shared across replicas, or an in-memory cache otherwise.
+func newHTTPCache() httpcache.Cache {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return httpcache.NewMemoryCache()
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.WithError(err).Warn("Failed to parse REDIS_URL, falling back to in-memory HTTP cache")
+		return httpcache.NewMemoryCache()
+	}
+	return &redisCache{client: redis.NewClient(opts)}
+}
+
+// redisCache adapts a go-redis client to httpcache.Cache so cached GitHub
+// API responses can be shared across server replicas instead of being
+// rebuilt by each one independently.
+type redisCache struct {
+	client *redis.Client
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool) {
+	val, err := c.client.Get(context.Background(), key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+func (c *redisCache) Set(key string, responseBytes []byte) {
+	if err := c.client.Set(context.Background(), key, responseBytes, 24*time.Hour).Err(); err != nil {
+		log.WithError(err).Warn("Failed to write HTTP cache entry to Redis")
+	}
+}
+
+func (c *redisCache) Delete(key string) {
+	if err := c.client.Del(context.Background(), key).Err(); err != nil {
+		log.WithError(err).Warn("Failed to delete HTTP cache entry from Redis")
+	}
+}
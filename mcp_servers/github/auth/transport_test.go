@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func rateLimitResponse(remaining int, resetAt time.Time) *http.Response {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	resp.Header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	resp.Header.Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+	return resp
+}
+
+func TestTokenLimiterUpdateBelowThresholdBlocksUntilReset(t *testing.T) {
+	l := &tokenLimiter{}
+	resetAt := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	l.update(rateLimitResponse(rateLimitThreshold-1, resetAt), "pat")
+
+	if !l.blockedUntil.Equal(resetAt) {
+		t.Errorf("blockedUntil = %v, want %v", l.blockedUntil, resetAt)
+	}
+}
+
+func TestTokenLimiterUpdateAboveThresholdDoesNotBlock(t *testing.T) {
+	l := &tokenLimiter{}
+	l.update(rateLimitResponse(rateLimitThreshold+10, time.Now().Add(time.Minute)), "pat")
+
+	if !l.blockedUntil.IsZero() {
+		t.Errorf("blockedUntil = %v, want zero value", l.blockedUntil)
+	}
+}
+
+func TestTokenLimiterUpdateSecondaryRateLimitBacksOff(t *testing.T) {
+	l := &tokenLimiter{}
+	resp := &http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header)}
+	resp.Header.Set("Retry-After", "2")
+
+	before := time.Now()
+	l.update(resp, "pat")
+
+	if l.backoff < 2*time.Second {
+		t.Errorf("backoff = %v, want at least 2s from Retry-After", l.backoff)
+	}
+	if !l.blockedUntil.After(before) {
+		t.Errorf("blockedUntil = %v, want after %v", l.blockedUntil, before)
+	}
+}
+
+func TestTokenLimiterUpdateSecondaryRateLimitDoublesOnRepeatedHits(t *testing.T) {
+	l := &tokenLimiter{}
+	resp := &http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header)}
+	resp.Header.Set("Retry-After", "1")
+
+	l.update(resp, "pat")
+	first := l.backoff
+	l.update(resp, "pat")
+	second := l.backoff
+
+	if second != 2*first {
+		t.Errorf("second backoff = %v, want double the first (%v)", second, first)
+	}
+}
+
+func TestTokenLimiterUpdateClearsBackoffOnCleanResponse(t *testing.T) {
+	l := &tokenLimiter{}
+	forbidden := &http.Response{StatusCode: http.StatusForbidden, Header: make(http.Header)}
+	forbidden.Header.Set("Retry-After", "1")
+	l.update(forbidden, "pat")
+	if l.backoff == 0 {
+		t.Fatal("expected backoff to be set after a secondary rate limit hit")
+	}
+
+	l.update(rateLimitResponse(rateLimitThreshold+10, time.Now().Add(time.Minute)), "pat")
+	if l.backoff != 0 {
+		t.Errorf("backoff = %v, want 0 after a clean response", l.backoff)
+	}
+}
+
+func TestTokenLimiterWaitReturnsImmediatelyWhenNotBlocked(t *testing.T) {
+	l := &tokenLimiter{}
+	if err := l.wait(context.Background()); err != nil {
+		t.Errorf("wait() returned error: %v", err)
+	}
+}
+
+func TestTokenLimiterWaitRespectsContextCancellation(t *testing.T) {
+	l := &tokenLimiter{blockedUntil: time.Now().Add(time.Hour)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait() with an already-canceled context returned nil error")
+	}
+}
+
+func TestCredentialKeyPrefersTokenOverInstallation(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tokenContextKey, "pat-token")
+	ctx = context.WithValue(ctx, installationContextKey, int64(42))
+
+	if got, want := credentialKey(ctx), "token:pat-token"; got != want {
+		t.Errorf("credentialKey() = %q, want %q", got, want)
+	}
+}
+
+func TestCredentialKeyFallsBackToInstallation(t *testing.T) {
+	ctx := context.WithValue(context.Background(), installationContextKey, int64(42))
+
+	if got, want := credentialKey(ctx), "installation:42"; got != want {
+		t.Errorf("credentialKey() = %q, want %q", got, want)
+	}
+}
+
+func TestCredentialKeyDistinguishesInstallations(t *testing.T) {
+	a := context.WithValue(context.Background(), installationContextKey, int64(1))
+	b := context.WithValue(context.Background(), installationContextKey, int64(2))
+
+	if credentialKey(a) == credentialKey(b) {
+		t.Error("credentialKey() returned the same key for two different installations")
+	}
+}
+
+func TestCredentialKeyFallsBackToOAuth2Session(t *testing.T) {
+	ctx := context.WithValue(context.Background(), sessionContextKey, "sess-1")
+
+	if got, want := credentialKey(ctx), "session:sess-1"; got != want {
+		t.Errorf("credentialKey() = %q, want %q", got, want)
+	}
+}
+
+func TestCredentialKeyDistinguishesOAuth2Sessions(t *testing.T) {
+	a := context.WithValue(context.Background(), sessionContextKey, "sess-1")
+	b := context.WithValue(context.Background(), sessionContextKey, "sess-2")
+
+	if credentialKey(a) == credentialKey(b) {
+		t.Error("credentialKey() returned the same key for two different OAuth2 sessions")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// syncResponseWriter serializes writes to an http.ResponseWriter so the SSE
+// handler goroutine and connTracker.drain's shutdown-notice goroutine can
+// never write to the same connection at once -- http.ResponseWriter gives
+// no such guarantee on its own, and writing to it concurrently would
+// corrupt the SSE stream.
+type syncResponseWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+func (w *syncResponseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *syncResponseWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *syncResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flusher.Flush()
+}
+
+// notifyShutdown pushes an SSE shutdown frame into the connection under the
+// same lock every other write to it takes, so it can never interleave with
+// (or race) a frame the handler is writing concurrently.
+func (w *syncResponseWriter) notifyShutdown() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprint(w.ResponseWriter, "event: shutdown\ndata: server is shutting down, please reconnect elsewhere\n\n")
+	w.flusher.Flush()
+}
+
+// connTracker tracks active SSE and streamable-HTTP MCP sessions so runServer
+// can drain them on shutdown instead of aborting them mid-stream: it refuses
+// new connections once draining, tells open SSE clients to reconnect
+// elsewhere, and gives everything already in flight a grace period to finish
+// before httpServer.Shutdown is called.
+type connTracker struct {
+	draining atomic.Bool
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	sseConns map[*syncResponseWriter]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{sseConns: make(map[*syncResponseWriter]struct{})}
+}
+
+// guard wraps next so it refuses new connections with a 503 once draining is
+// set, and tracks every accepted request in wg until it returns.
+func (t *connTracker) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.draining.Load() {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		t.wg.Add(1)
+		defer t.wg.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guardSSE is like guard, but for streaming responses it also wraps the
+// ResponseWriter in a syncResponseWriter and registers it so drain can push
+// an "event: shutdown" frame into it, safely serialized against whatever
+// the handler is writing, before waiting for it to close.
+func (t *connTracker) guardSSE(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.draining.Load() {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.wg.Add(1)
+			defer t.wg.Done()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &syncResponseWriter{ResponseWriter: w, flusher: flusher}
+		t.mu.Lock()
+		t.sseConns[sw] = struct{}{}
+		t.mu.Unlock()
+
+		t.wg.Add(1)
+		defer func() {
+			t.mu.Lock()
+			delete(t.sseConns, sw)
+			t.mu.Unlock()
+			t.wg.Done()
+		}()
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// drain flips the draining flag, notifies every open SSE stream, then waits
+// up to timeout for tracked connections to finish on their own before
+// returning control to the caller for the final httpServer.Shutdown.
+func (t *connTracker) drain(timeout time.Duration) {
+	t.draining.Store(true)
+
+	t.mu.Lock()
+	conns := make([]*syncResponseWriter, 0, len(t.sseConns))
+	for c := range t.sseConns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		c.notifyShutdown()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warn("Timed out waiting for active MCP sessions to drain")
+	}
+}
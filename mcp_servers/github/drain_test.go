@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnTrackerGuardRejectsWhenDraining(t *testing.T) {
+	tracker := newConnTracker()
+	tracker.draining.Store(true)
+
+	handler := tracker.guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not run while draining")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestConnTrackerDrainWaitsForInFlightRequests(t *testing.T) {
+	tracker := newConnTracker()
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	handler := tracker.guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	requestDone := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/mcp/", nil))
+		close(requestDone)
+	}()
+	<-started
+
+	drained := make(chan struct{})
+	go func() {
+		tracker.drain(time.Second)
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-requestDone
+	<-drained
+}
+
+func TestConnTrackerGuardSSENotifiesShutdown(t *testing.T) {
+	tracker := newConnTracker()
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	done := make(chan struct{})
+
+	rec := httptest.NewRecorder()
+	handler := tracker.guardSSE(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finished
+	}))
+
+	go func() {
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sse", nil))
+		close(done)
+	}()
+
+	<-started
+	tracker.drain(50 * time.Millisecond)
+	close(finished)
+	<-done
+
+	if body := rec.Body.String(); !strings.Contains(body, "event: shutdown") {
+		t.Errorf("response body = %q, want an \"event: shutdown\" frame", body)
+	}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,84 +13,43 @@ import (
 	"github.com/github/github-mcp-server/pkg/translations"
 	gogithub "github.com/google/go-github/v69/github"
 	"github.com/joho/godotenv"
+	"github.com/klavis-ai/klavis/mcp_servers/github/auth"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
-// Define request context key type for safety
-type contextKey string
-
-const tokenContextKey contextKey = "auth_token"
-
-func extractAccessToken(r *http.Request) string {
-	// First try AUTH_DATA environment variable
-	authData := os.Getenv("AUTH_DATA")
-
-	if authData == "" {
-		// Extract from x-auth-data header
-		headerData := r.Header.Get("x-auth-data")
-		if headerData != "" {
-			// Decode base64
-			decoded, err := base64.StdEncoding.DecodeString(headerData)
-			if err != nil {
-				log.WithError(err).Warn("Failed to decode base64 auth data")
-				return ""
-			}
-			authData = string(decoded)
-		}
-	}
-
-	if authData == "" {
-		return ""
-	}
-
-	// Try to parse as JSON
-	var authJSON map[string]interface{}
-	if err := json.Unmarshal([]byte(authData), &authJSON); err != nil {
-		log.WithError(err).Warn("Failed to parse auth data JSON")
-		return ""
-	}
-
-	// Extract access_token field
-	if accessToken, ok := authJSON["access_token"].(string); ok {
-		return accessToken
-	}
-
-	return ""
-}
-
 func runServer() error {
 	// Create app context
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	appCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	t, _ := translations.TranslationHelper()
 
-	// Create a context function to extract the token from request headers
+	authenticator, err := auth.New()
+	if err != nil {
+		return fmt.Errorf("configuring authenticator: %w", err)
+	}
+
+	// Create a context function to extract the caller's credential from the
+	// request. Which credential it looks for (bearer token, OAuth2 session
+	// cookie, GitHub App installation) depends on AUTH_MODE. The returned
+	// context is also canceled the moment the server starts shutting down,
+	// so a GitHub API call in flight for this request aborts instead of
+	// racing httpServer.Shutdown.
 	contextFunc := func(ctx context.Context, r *http.Request) context.Context {
-		// Extract from x-auth-data header
-		token := extractAccessToken(r)
-		if token != "" {
-			return context.WithValue(ctx, tokenContextKey, token)
+		ctx = cancelOnShutdown(ctx, appCtx)
+		authedCtx, err := authenticator.Authenticate(ctx, r)
+		if err != nil {
+			log.WithError(err).Warn("Failed to authenticate request")
+			return ctx
 		}
-
-		return ctx
+		return authedCtx
 	}
 
 	// Create a function that returns a GitHub client for each request
 	getClient := func(ctx context.Context) (*gogithub.Client, error) {
-		// Extract token from context
-		tokenValue := ctx.Value(tokenContextKey)
-		token, ok := tokenValue.(string)
-		if !ok || token == "" {
-			log.Warn("No auth token found in context")
-			return gogithub.NewClient(nil), nil
-		}
-
-		// Create authenticated client
-		client := gogithub.NewClient(nil).WithAuthToken(token)
-		// client.UserAgent = fmt.Sprintf("github-mcp-server/%s")
-		return client, nil
+		return authenticator.Client(ctx)
 	}
 
 	// Get port from environment variable (Cloud Run sets PORT)
@@ -125,10 +82,23 @@ func runServer() error {
 		server.WithStateLess(true),
 	)
 
-	// Register handlers on different paths
-	mux.Handle("/sse", sseServer)
-	mux.Handle("/message", sseServer)
-	mux.Handle("/mcp/", streamableHttpServer)
+	// Register handlers on different paths. Every MCP-facing route is
+	// wrapped in tracker so a SIGTERM can drain active sessions instead of
+	// aborting them; /metrics and any auth-mode routes below are cheap
+	// request/response calls that don't need draining.
+	tracker := newConnTracker()
+	mux.Handle("/sse", tracker.guardSSE(sseServer))
+	mux.Handle("/message", tracker.guard(sseServer))
+	mux.Handle("/mcp/", tracker.guard(streamableHttpServer))
+	mux.Handle("/metrics", promhttp.Handler())
+
+	// Auth modes that need their own browser-facing routes (currently just
+	// OAuth2's login/callback) register them here.
+	if registrar, ok := authenticator.(interface {
+		RegisterRoutes(*http.ServeMux)
+	}); ok {
+		registrar.RegisterRoutes(mux)
+	}
 
 	// Start the server with a goroutine
 	serverErr := make(chan error, 1)
@@ -143,8 +113,10 @@ func runServer() error {
 	select {
 	case err := <-serverErr:
 		return err
-	case <-ctx.Done():
-		log.Info("Shutdown signal received")
+	case <-appCtx.Done():
+		log.Info("Shutdown signal received, draining active MCP sessions")
+		tracker.drain(shutdownGrace())
+
 		// timeout context for shutdown
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -156,6 +128,38 @@ func runServer() error {
 	return nil
 }
 
+// shutdownGrace is how long drain waits for active SSE and streamable-HTTP
+// sessions to finish on their own before the server closes them. Override
+// with SHUTDOWN_GRACE (a Go duration string, e.g. "45s").
+func shutdownGrace() time.Duration {
+	const defaultGrace = 30 * time.Second
+	raw := os.Getenv("SHUTDOWN_GRACE")
+	if raw == "" {
+		return defaultGrace
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.WithError(err).Warnf("Invalid SHUTDOWN_GRACE %q, using default %s", raw, defaultGrace)
+		return defaultGrace
+	}
+	return d
+}
+
+// cancelOnShutdown derives a context from reqCtx that is also canceled the
+// moment appCtx is done, so a request's context reflects server shutdown
+// without callers needing to select on two contexts themselves.
+func cancelOnShutdown(reqCtx, appCtx context.Context) context.Context {
+	derived, cancel := context.WithCancel(reqCtx)
+	go func() {
+		select {
+		case <-appCtx.Done():
+			cancel()
+		case <-derived.Done():
+		}
+	}()
+	return derived
+}
+
 func main() {
 	_ = godotenv.Load(".env")
 	if err := runServer(); err != nil {